@@ -0,0 +1,189 @@
+package oasis_sdk
+
+// disco.go implements XEP-0030 Service Discovery, used to find the server's
+// HTTP upload component (and any other component-hosted service) by walking
+// disco#items for the user's domain and disco#info for each returned item.
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"mellium.im/xmpp/jid"
+	"mellium.im/xmpp/stanza"
+)
+
+const (
+	nsDiscoItems = "http://jabber.org/protocol/disco#items"
+	nsDiscoInfo  = "http://jabber.org/protocol/disco#info"
+	nsDataForm   = "jabber:x:data"
+	nsHTTPUpload = "urn:xmpp:http:upload:0"
+	discoTimeout = 30 * time.Second
+)
+
+// DiscoItem is one <item/> returned from a disco#items query.
+type DiscoItem struct {
+	JID  jid.JID `xml:"jid,attr"`
+	Name string  `xml:"name,attr"`
+}
+
+type discoItemsQuery struct {
+	XMLName xml.Name    `xml:"http://jabber.org/protocol/disco#items query"`
+	Items   []DiscoItem `xml:"item"`
+}
+
+// DiscoComponent describes a component discovered via disco#items/disco#info,
+// including the features and data form it advertised.
+type DiscoComponent struct {
+	JID      jid.JID
+	Name     string
+	Features []string
+	Form     map[string][]string
+}
+
+type discoFeature struct {
+	Var string `xml:"var,attr"`
+}
+
+type discoFormField struct {
+	Var    string   `xml:"var,attr"`
+	Values []string `xml:"value"`
+}
+
+type discoForm struct {
+	XMLName xml.Name         `xml:"jabber:x:data x"`
+	Fields  []discoFormField `xml:"field"`
+}
+
+type discoInfoQuery struct {
+	XMLName  xml.Name       `xml:"http://jabber.org/protocol/disco#info query"`
+	Features []discoFeature `xml:"feature"`
+	Form     discoForm      `xml:"x"`
+}
+
+// DiscoverServices walks disco#items for the user's domain, then disco#info
+// for each returned item, caching the full result on the client so MUC
+// discovery, PubSub, and MAM support can reuse it later without another
+// round trip. Any item advertising urn:xmpp:http:upload:0 populates
+// client.HttpUploadComponent from its max-file-size form field. It returns
+// every discovered component so callers can choose among multiple upload
+// hosts, and should be re-run after a reconnect since components can change.
+func (client *XmppClient) DiscoverServices(ctx context.Context) ([]DiscoComponent, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoTimeout)
+	defer cancel()
+
+	items, err := client.discoItems(ctx, client.JID.Domain())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disco#items: %w", err)
+	}
+
+	components := make([]DiscoComponent, 0, len(items))
+	for _, item := range items {
+		info, err := client.discoInfo(ctx, item.JID)
+		if err != nil {
+			client.logger().Warn("failed to query disco#info", "jid", item.JID.String(), "error", err)
+			continue
+		}
+
+		component := DiscoComponent{
+			JID:      item.JID,
+			Name:     item.Name,
+			Features: make([]string, len(info.Features)),
+			Form:     make(map[string][]string, len(info.Form.Fields)),
+		}
+		for i, f := range info.Features {
+			component.Features[i] = f.Var
+		}
+		for _, f := range info.Form.Fields {
+			component.Form[f.Var] = f.Values
+		}
+
+		components = append(components, component)
+
+		if hasFeature(component.Features, nsHTTPUpload) {
+			client.applyUploadComponent(component)
+		}
+	}
+
+	client.DiscoCache = components
+	return components, nil
+}
+
+func hasFeature(features []string, want string) bool {
+	for _, f := range features {
+		if f == want {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUploadComponent populates client.HttpUploadComponent from a
+// discovered component's max-file-size form field.
+func (client *XmppClient) applyUploadComponent(component DiscoComponent) {
+	maxSize := int64(0)
+	if values, ok := component.Form["max-file-size"]; ok && len(values) > 0 {
+		fmt.Sscanf(values[0], "%d", &maxSize)
+	}
+
+	client.HttpUploadComponent = &HttpUploadComponent{
+		Jid:         component.JID,
+		MaxFileSize: maxSize,
+	}
+}
+
+func (client *XmppClient) discoItems(ctx context.Context, to jid.JID) ([]DiscoItem, error) {
+	header := stanza.IQ{
+		ID:   uuid.New().String(),
+		To:   to,
+		Type: stanza.GetIQ,
+	}
+	query := struct {
+		XMLName xml.Name `xml:"http://jabber.org/protocol/disco#items query"`
+	}{}
+
+	t, err := client.Session.EncodeIQElement(ctx, query, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send disco#items iq: %w", err)
+	}
+	defer t.Close()
+
+	response := &struct {
+		stanza.IQ
+		Query discoItemsQuery `xml:"query"`
+	}{}
+	if err := xml.NewTokenDecoder(t).Decode(response); err != nil {
+		return nil, fmt.Errorf("failed to decode disco#items response: %w", err)
+	}
+
+	return response.Query.Items, nil
+}
+
+func (client *XmppClient) discoInfo(ctx context.Context, to jid.JID) (*discoInfoQuery, error) {
+	header := stanza.IQ{
+		ID:   uuid.New().String(),
+		To:   to,
+		Type: stanza.GetIQ,
+	}
+	query := struct {
+		XMLName xml.Name `xml:"http://jabber.org/protocol/disco#info query"`
+	}{}
+
+	t, err := client.Session.EncodeIQElement(ctx, query, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send disco#info iq: %w", err)
+	}
+	defer t.Close()
+
+	response := &struct {
+		stanza.IQ
+		Query discoInfoQuery `xml:"query"`
+	}{}
+	if err := xml.NewTokenDecoder(t).Decode(response); err != nil {
+		return nil, fmt.Errorf("failed to decode disco#info response: %w", err)
+	}
+
+	return &response.Query, nil
+}
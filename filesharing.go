@@ -0,0 +1,105 @@
+package oasis_sdk
+
+// filesharing.go implements XEP-0447 Stateless File Sharing: wrapping an
+// UploadResult from upload.go in a <file-sharing/> reference that modern
+// clients can verify against the XEP-0300 hashes, alongside a XEP-0066 OOB
+// <x/> sibling so clients that only understand the legacy link still get
+// something clickable.
+
+import (
+	"encoding/xml"
+	"net/url"
+	"path"
+
+	"mellium.im/xmpp/jid"
+	"mellium.im/xmpp/stanza"
+)
+
+// fileShareHash is a XEP-0300 <hash/> element: an algorithm name from the
+// IANA hash-function registry ("sha-256", "sha3-256") and its base64 digest.
+type fileShareHash struct {
+	Algo  string `xml:"algo,attr"`
+	Value string `xml:",chardata"`
+}
+
+// fileShareMetadata is the XEP-0446 <file/> description embedded in a
+// file-sharing reference.
+type fileShareMetadata struct {
+	XMLName   xml.Name        `xml:"urn:xmpp:file:metadata:0 file"`
+	Name      string          `xml:"name,omitempty"`
+	Size      int64           `xml:"size,omitempty"`
+	MediaType string          `xml:"media-type,omitempty"`
+	Hashes    []fileShareHash `xml:"urn:xmpp:hashes:2 hash"`
+}
+
+// fileShareURLData is a XEP-0447 <url-data/> source pointing at the HTTP
+// upload's GET URL.
+type fileShareURLData struct {
+	XMLName xml.Name `xml:"http://jabber.org/protocol/url-data url-data"`
+	Target  string   `xml:"target,attr"`
+}
+
+type fileShareSources struct {
+	URLData fileShareURLData `xml:"url-data"`
+}
+
+// fileSharing is the XEP-0447 <file-sharing/> payload.
+type fileSharing struct {
+	XMLName xml.Name          `xml:"urn:xmpp:sfs:0 file-sharing"`
+	File    fileShareMetadata `xml:"file"`
+	Sources fileShareSources  `xml:"sources"`
+}
+
+// oobFallback is the legacy XEP-0066 Out of Band Data sibling carried
+// alongside file-sharing so clients that predate XEP-0447 still render a
+// usable link.
+type oobFallback struct {
+	XMLName xml.Name `xml:"jabber:x:oob x"`
+	URL     string   `xml:"url"`
+}
+
+// FileShareMessage is the <message/> SendFileShare encodes: a XEP-0447
+// file-sharing reference with a XEP-0066 OOB fallback as a sibling element.
+type FileShareMessage struct {
+	stanza.Message
+	FileSharing fileSharing `xml:"urn:xmpp:sfs:0 file-sharing"`
+	OOB         oobFallback `xml:"jabber:x:oob x"`
+}
+
+// SendFileShare sends to a XEP-0447 Stateless File Sharing reference to an
+// already-uploaded file, embedding result's size, content type, and hashes
+// in the <file/> metadata so the recipient can verify the download instead
+// of trusting the URL alone. The file name is recovered from the last path
+// segment of result.GetURL, since XEP-0363 upload slots preserve it there.
+func (self *XmppClient) SendFileShare(to jid.JID, result UploadResult) error {
+	hashes := make([]fileShareHash, 0, len(result.Hashes))
+	for algo, value := range result.Hashes {
+		hashes = append(hashes, fileShareHash{Algo: algo, Value: value})
+	}
+
+	name := result.GetURL
+	if u, err := url.Parse(result.GetURL); err == nil {
+		name = path.Base(u.Path)
+	}
+
+	msg := FileShareMessage{
+		Message: stanza.Message{
+			To:   to,
+			Type: stanza.ChatMessage,
+		},
+		FileSharing: fileSharing{
+			File: fileShareMetadata{
+				Name:      name,
+				Size:      result.Size,
+				MediaType: result.ContentType,
+				Hashes:    hashes,
+			},
+			Sources: fileShareSources{
+				URLData: fileShareURLData{Target: result.GetURL},
+			},
+		},
+		OOB: oobFallback{URL: result.GetURL},
+	}
+
+	return self.Session.Encode(self.Ctx, msg)
+}
@@ -0,0 +1,60 @@
+package oasis_sdk
+
+// logger.go defines the Logger interface used throughout the connect/join
+// path in place of fmt.Println/panic, so the library stays usable inside
+// daemons that don't want stdout noise or a stray panic killing the host
+// process.
+
+import "log/slog"
+
+// Logger is the structured logging interface XmppClient routes its internal
+// diagnostics through. Any *slog.Logger satisfies it via SlogLogger.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// noopLogger discards everything. It's the default so existing callers see
+// silence instead of stdout spam until they opt in to a real Logger.
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...any) {}
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
+
+// SlogLogger adapts a *slog.Logger to the Logger interface.
+type SlogLogger struct {
+	*slog.Logger
+}
+
+// NewSlogLogger wraps l as a Logger. A nil l falls back to slog.Default().
+func NewSlogLogger(l *slog.Logger) SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return SlogLogger{Logger: l}
+}
+
+// logger returns self.Logger, or a no-op logger if none was configured.
+func (self *XmppClient) logger() Logger {
+	if self.Logger == nil {
+		return noopLogger{}
+	}
+	return self.Logger
+}
+
+// logWriter adapts a Logger to an io.Writer so it can be used as the
+// session's TeeIn/TeeOut, for wire-level debugging when XmppClient.Debug is
+// set.
+type logWriter struct {
+	log   Logger
+	label string
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.log.Debug(w.label, "data", string(p))
+	return len(p), nil
+}
@@ -2,14 +2,11 @@ package oasis_sdk
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/xml"
 	"errors"
-	"fmt"
+	"io"
 
-	"mellium.im/sasl"
 	"mellium.im/xmpp"
-	"mellium.im/xmpp/dial"
 	"mellium.im/xmpp/jid"
 	"mellium.im/xmpp/muc"
 	"mellium.im/xmpp/mux"
@@ -18,20 +15,47 @@ import (
 
 type connectionErrHandler func(err error)
 
-/*
-Connect dials the server and starts receiving the events.
-If blocking is true, this method will not exit until the xmpp connection is no longer being maintained.
-If blocking is false, this method will exit as soon as a connection is created, and errors will be emitted
-through the callback onErr
-*/
-func (self *XmppClient) Connect(blocking bool, onErr connectionErrHandler) error {
-	d := dial.Dialer{}
+// dial opens the connection and negotiates the XML stream, including
+// BindResource/StartTLS/SASL. self.smQueue is populated so SendTracked/
+// SMMetrics have a local queue to record into, bounded by self.SMQueueSize
+// (0 falls back to defaultSMQueueSize) - see streammanagement.go for why
+// that queue is local bookkeeping only, not real XEP-0198. The transport
+// itself - direct TLS, no TLS, a proxy, or a custom SASL mechanism list - is
+// controlled by self.Login.TransportConfig; see transport.go's dial method
+// for why DirectTLS and a proxy both bypass dial.Dialer.DialServer.
+func (self *XmppClient) dial() error {
+	transport := self.Login.TransportConfig
+	if err := transport.Validate(); err != nil {
+		return err
+	}
 
-	conn, err := d.DialServer(self.Ctx, "tcp", *self.JID, *self.Server)
+	conn, err := transport.dial(self.Ctx, *self.JID, *self.Server)
 	if err != nil {
 		return errors.New("Could not connect stage 1 - " + err.Error())
 	}
 
+	if self.smQueue == nil {
+		self.smQueue = newSMResendQueue(self.SMQueueSize)
+	}
+
+	var teeIn, teeOut io.Writer
+	if self.Debug {
+		teeIn = logWriter{self.logger(), "stream in"}
+		teeOut = logWriter{self.logger(), "stream out"}
+	}
+
+	features := []xmpp.StreamFeature{
+		xmpp.BindResource(),
+	}
+	if transport == nil || !transport.NoTLS {
+		if transport == nil || !transport.DirectTLS {
+			features = append(features, xmpp.StartTLS(transport.tlsConfig(*self.Server)))
+		}
+	}
+	features = append(features,
+		xmpp.SASL("", self.Login.Password, transport.saslMechanisms()...),
+	)
+
 	self.Session, err = xmpp.NewSession(
 		self.Ctx,
 		self.JID.Domain(),
@@ -40,17 +64,10 @@ func (self *XmppClient) Connect(blocking bool, onErr connectionErrHandler) error
 		0,
 		xmpp.NewNegotiator(func(*xmpp.Session, *xmpp.StreamConfig) xmpp.StreamConfig {
 			return xmpp.StreamConfig{
-				Lang: "en",
-				Features: []xmpp.StreamFeature{
-					xmpp.BindResource(),
-					xmpp.StartTLS(&tls.Config{
-						ServerName: *self.Server,
-						MinVersion: tls.VersionTLS12,
-					}),
-					xmpp.SASL("", self.Login.Password, sasl.ScramSha1Plus, sasl.ScramSha1, sasl.Plain),
-				},
-				TeeIn:  nil,
-				TeeOut: nil,
+				Lang:     "en",
+				Features: features,
+				TeeIn:    teeIn,
+				TeeOut:   teeOut,
 			}
 		},
 		))
@@ -59,41 +76,63 @@ func (self *XmppClient) Connect(blocking bool, onErr connectionErrHandler) error
 	}
 
 	if self.Session == nil {
-		panic("session never got set")
+		return errors.New("session never got set after xmpp.NewSession returned no error")
+	}
+
+	return nil
+}
+
+/*
+Connect dials the server and starts receiving the events.
+If blocking is true, this method will not exit until the xmpp connection is no longer being maintained.
+If blocking is false, this method will exit as soon as a connection is created, and errors will be emitted
+through the callback onErr.
+
+If the connection drops, it is retried according to self.ReconnectPolicy: a
+fresh bind and rejoin of self.mucsToJoin. self.StateHandler, if set, is
+notified of every transition.
+*/
+func (self *XmppClient) Connect(blocking bool, onErr connectionErrHandler) error {
+	if err := self.dial(); err != nil {
+		return err
 	}
 
 	go func() {
 		n := len(self.mucsToJoin)
 		for i, mucJID := range self.mucsToJoin {
-			fmt.Printf("Joining muc %d/%d \"%s\" with nickname \"%s\"\n", i+1, n, mucJID.Bare().String(), mucJID.Resourcepart())
+			self.logger().Info("joining muc", "index", i+1, "total", n, "muc", mucJID.Bare().String(), "nickname", mucJID.Resourcepart())
 			ch, err := self.MucClient.Join(self.Ctx, mucJID, self.Session)
 			if err != nil {
-				println(err.Error())
+				self.logger().Error("failed to join muc", "muc", mucJID.Bare().String(), "error", err)
 				continue
 			}
 			self.mucChannels[mucJID.String()] = ch
-			fmt.Printf("joined muc %d/%d\n", i+1, n)
+			self.logger().Info("joined muc", "index", i+1, "total", n)
 		}
 	}()
 
 	if blocking {
-		return self.startServing()
-	} else {
-		//serve in a thread
-		go func() {
-			err := self.startServing()
-
-			//if error, try callback error handler, otherwise panic
-			if err != nil {
-				if onErr == nil {
-					panic(err)
-				} else {
-					onErr(err)
-				}
+		err := self.startServing()
+		if err != nil {
+			if onErr != nil {
+				onErr(err)
 			}
-		}()
+			self.reconnectLoop(onErr)
+		}
+		return nil
 	}
 
+	//serve in a thread
+	go func() {
+		err := self.startServing()
+		if err != nil {
+			if onErr != nil {
+				onErr(err)
+			}
+			self.reconnectLoop(onErr)
+		}
+	}()
+
 	return nil
 }
 
@@ -110,7 +149,7 @@ func (self *XmppClient) MarkAsDelivered(orignalMSG *XMPPChatMessage) {
 	}
 	err := self.Session.Encode(self.Ctx, msg)
 	if err != nil {
-		fmt.Println(err.Error())
+		self.logger().Error("failed to send delivery receipt", "error", err)
 	}
 }
 
@@ -162,17 +201,6 @@ func CreateClient(
 	readReceiptHandler ReadReceiptHandler,
 ) (*XmppClient, error) {
 
-	mucJIDs := make([]jid.JID, 0, len(login.MucsToJoin))
-	for _, jidStr := range login.MucsToJoin {
-		//join with default displayname
-		j, err := jid.Parse(jidStr + "/" + login.DisplayName)
-		if err != nil {
-			fmt.Println("Error parsing MUC jid: " + err.Error())
-			continue
-		}
-		mucJIDs = append(mucJIDs, j)
-	}
-
 	// create client object
 	client := &XmppClient{
 		Login:                  login,
@@ -181,11 +209,22 @@ func CreateClient(
 		chatstateHandler:       chatstateHandler,
 		deliveryReceiptHandler: deliveryReceiptHandler,
 		readReceiptHandler:     readReceiptHandler,
-		mucsToJoin:             mucJIDs,
 		mucChannels:            make(map[string]*muc.Channel),
 	}
 	client.Ctx, client.CtxCancel = context.WithCancel(context.Background())
 
+	mucJIDs := make([]jid.JID, 0, len(login.MucsToJoin))
+	for _, jidStr := range login.MucsToJoin {
+		//join with default displayname
+		j, err := jid.Parse(jidStr + "/" + login.DisplayName)
+		if err != nil {
+			client.logger().Error("failed to parse muc jid", "jid", jidStr, "error", err)
+			continue
+		}
+		mucJIDs = append(mucJIDs, j)
+	}
+	client.mucsToJoin = mucJIDs
+
 	client.MucClient = &muc.Client{}
 	messageNS := xml.Name{
 		Local: "body",
@@ -0,0 +1,240 @@
+package oasis_sdk
+
+// streammanagement.go provides the reconnect/backoff loop that rebuilds the
+// session after a drop and rejoins mucsToJoin, plus SendTracked/SMMetrics: a
+// bounded, purely local record of stanzas recently sent through
+// SendTracked. This is NOT XEP-0198 (urn:xmpp:sm:3) - mellium.im/xmpp has no
+// sm subpackage to build that on, and there is no wire protocol here at
+// all: no <enable/>, no <a h='...'/> ack, no <resume/>. Every reconnect is a
+// fresh bind; nothing is or ever was automatically replayed from the queue,
+// since with no ack channel there is no way to tell a delivered stanza from
+// an undelivered one, and resending blindly would duplicate messages the
+// server already got. The queue exists only so SMMetrics can report how
+// much SendTracked traffic is in flight; treat it as an observability
+// counter, not a delivery guarantee.
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrReconnectExhausted is returned to the Connect error callback once
+// ReconnectPolicy.MaxAttempts has been reached with no successful reconnect.
+var ErrReconnectExhausted = errors.New("oasis_sdk: exhausted reconnect attempts")
+
+// ConnectionState describes a transition in the connect/reconnect lifecycle,
+// reported to callers through XmppClient.StateHandler.
+type ConnectionState int
+
+const (
+	StateConnecting ConnectionState = iota
+	StateFullReconnect
+	StateFailed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateFullReconnect:
+		return "full-reconnect"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionStateHandler is invoked whenever the reconnection loop changes
+// state, so callers can reflect connectivity in their UI.
+type ConnectionStateHandler func(state ConnectionState)
+
+// ReconnectPolicy controls how Connect retries a dropped connection.
+// A nil policy on XmppClient falls back to DefaultReconnectPolicy.
+type ReconnectPolicy struct {
+	MaxAttempts    int // 0 means retry forever
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64 // fraction of the backoff to randomize, e.g. 0.2 for +/-20%
+}
+
+// DefaultReconnectPolicy is used when XmppClient.ReconnectPolicy is nil.
+var DefaultReconnectPolicy = &ReconnectPolicy{
+	MaxAttempts:    0,
+	InitialBackoff: time.Second,
+	MaxBackoff:     2 * time.Minute,
+	Jitter:         0.2,
+}
+
+// backoff returns how long to wait before reconnect attempt n (1-indexed).
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delta := float64(d) * p.Jitter
+		d += time.Duration(rand.Float64()*2*delta - delta)
+	}
+	return d
+}
+
+// SMMetrics exposes a point-in-time count of how much SendTracked traffic
+// smResendQueue is currently holding. There is no wire-level ack or replay
+// (see the file comment), so this is an observability counter, not a
+// measure of delivery or redelivery.
+type SMMetrics struct {
+	Queued int // stanzas recently sent through SendTracked, bounded by XmppClient.SMQueueSize
+}
+
+// smResendQueue records the most recent stanzas sent through SendTracked.
+// It is bounded: once full, the oldest entry is dropped rather than growing
+// without limit.
+type smResendQueue struct {
+	mu      sync.Mutex
+	maxSize int
+	pending []any
+}
+
+// defaultSMQueueSize bounds how many stanzas smResendQueue holds before
+// evicting the oldest, when XmppClient.SMQueueSize is left at zero.
+const defaultSMQueueSize = 512
+
+func newSMResendQueue(maxSize int) *smResendQueue {
+	if maxSize <= 0 {
+		maxSize = defaultSMQueueSize
+	}
+	return &smResendQueue{maxSize: maxSize}
+}
+
+// push records a stanza as sent.
+func (q *smResendQueue) push(v any) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending = append(q.pending, v)
+	if len(q.pending) > q.maxSize {
+		q.pending = q.pending[len(q.pending)-q.maxSize:]
+	}
+}
+
+func (q *smResendQueue) metrics() SMMetrics {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return SMMetrics{Queued: len(q.pending)}
+}
+
+// SendTracked encodes v to the active session the same way Session.Encode
+// would, additionally recording it in the local resend queue so SMMetrics
+// can report on it. It is not redelivered automatically on reconnect - see
+// the file comment - so callers who need at-least-once delivery must still
+// track and resend application-level acks themselves.
+func (self *XmppClient) SendTracked(v any) error {
+	if self.smQueue != nil {
+		self.smQueue.push(v)
+	}
+	return self.Session.Encode(self.Ctx, v)
+}
+
+// SMMetrics returns a snapshot of the local SendTracked queue, or a zero
+// value if nothing has been tracked yet.
+func (self *XmppClient) SMMetrics() SMMetrics {
+	if self.smQueue == nil {
+		return SMMetrics{}
+	}
+	return self.smQueue.metrics()
+}
+
+// reconnectLoop is run by Connect after the session drops. It retries with
+// exponential backoff; every successful reconnect is a fresh bind that
+// rejoins the cached mucsToJoin. It does not replay anything from the
+// SendTracked queue - see the file comment - since without a real ack there
+// is no way to tell whether the server already received a given stanza
+// before the drop, and resending blindly would duplicate it.
+func (self *XmppClient) reconnectLoop(onErr connectionErrHandler) {
+	policy := self.ReconnectPolicy
+	if policy == nil {
+		policy = DefaultReconnectPolicy
+	}
+
+	// Every pass through this outer loop represents one dropped connection;
+	// the inner loop is the backoff/retry sequence to get back online.
+	for {
+		reconnected := false
+
+		for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+			select {
+			case <-self.Ctx.Done():
+				return
+			default:
+			}
+
+			self.reportState(StateConnecting)
+
+			wait := policy.backoff(attempt)
+			self.logger().Info("reconnecting", "attempt", attempt, "wait", wait.String())
+			select {
+			case <-self.Ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			if err := self.dial(); err != nil {
+				self.logger().Warn("reconnect attempt failed", "attempt", attempt, "error", err)
+				continue
+			}
+
+			self.reportState(StateFullReconnect)
+			go self.rejoinCachedMUCs()
+
+			go func() {
+				if _, err := self.DiscoverServices(self.Ctx); err != nil {
+					self.logger().Warn("failed to re-run service discovery after reconnect", "error", err)
+				}
+			}()
+
+			reconnected = true
+			break
+		}
+
+		if !reconnected {
+			self.reportState(StateFailed)
+			if onErr != nil {
+				onErr(ErrReconnectExhausted)
+			}
+			return
+		}
+
+		// Blocks until this connection drops, then the outer loop reconnects again.
+		if err := self.startServing(); err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+}
+
+// rejoinCachedMUCs re-joins every room in mucsToJoin after a full
+// reconnect, mirroring the join loop Connect runs on first connect.
+func (self *XmppClient) rejoinCachedMUCs() {
+	n := len(self.mucsToJoin)
+	for i, mucJID := range self.mucsToJoin {
+		self.logger().Info("rejoining muc", "index", i+1, "total", n, "muc", mucJID.Bare().String(), "nickname", mucJID.Resourcepart())
+		ch, err := self.MucClient.Join(self.Ctx, mucJID, self.Session)
+		if err != nil {
+			self.logger().Error("failed to rejoin muc", "muc", mucJID.Bare().String(), "error", err)
+			continue
+		}
+		self.mucChannels[mucJID.String()] = ch
+	}
+}
+
+func (self *XmppClient) reportState(state ConnectionState) {
+	if self.StateHandler != nil {
+		self.StateHandler(state)
+	}
+}
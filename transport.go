@@ -0,0 +1,152 @@
+package oasis_sdk
+
+// transport.go extends how Connect dials the server: direct TLS for ports
+// like 5223, disabling TLS entirely for trusted local hops, a caller-supplied
+// tls.Config, routing through a SOCKS5/HTTP proxy, and overriding the SASL
+// mechanism list.
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+	"mellium.im/sasl"
+	"mellium.im/xmpp/dial"
+	"mellium.im/xmpp/jid"
+)
+
+// TransportConfig controls how Connect reaches the server, for bots running
+// behind Tor/VPN or against servers that only speak direct TLS. A nil
+// TransportConfig on LoginInfo keeps the previous behaviour: StartTLS on the
+// plain connection, TLS 1.2 minimum, with the default SASL mechanism list.
+type TransportConfig struct {
+	DirectTLS bool // negotiate TLS before the XML stream starts, e.g. for port 5223
+
+	NoTLS bool // reject the StartTLS feature entirely; requires an explicit opt-in, see Validate
+
+	TLSConfig *tls.Config // overrides the default ServerName/MinVersion TLS config when set
+
+	Proxy string // a socks5:// or http:// URL to dial through instead of connecting directly
+
+	SASLMechanisms []sasl.Mechanism // overrides the default SCRAM-SHA-1-PLUS/SCRAM-SHA-1/PLAIN list
+}
+
+// defaultSASLMechanisms is used when TransportConfig is nil or leaves
+// SASLMechanisms empty.
+var defaultSASLMechanisms = []sasl.Mechanism{sasl.ScramSha1Plus, sasl.ScramSha1, sasl.Plain}
+
+// Validate rejects transport combinations that are unsafe by default.
+// NoTLS with Plain authentication sends the password over an unencrypted
+// socket, so it must be requested explicitly rather than falling out of
+// some other combination of options.
+func (c *TransportConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.DirectTLS && c.NoTLS {
+		return errors.New("oasis_sdk: TransportConfig.DirectTLS and NoTLS are mutually exclusive")
+	}
+	if c.NoTLS && len(c.SASLMechanisms) == 0 {
+		// the default list includes PLAIN; an explicit list is the opt-in
+		return errors.New("oasis_sdk: NoTLS with PLAIN auth sends credentials unencrypted; set SASLMechanisms explicitly to opt in")
+	}
+	return nil
+}
+
+func (c *TransportConfig) tlsConfig(serverName string) *tls.Config {
+	if c != nil && c.TLSConfig != nil {
+		return c.TLSConfig
+	}
+	return &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+func (c *TransportConfig) saslMechanisms() []sasl.Mechanism {
+	if c == nil || len(c.SASLMechanisms) == 0 {
+		return defaultSASLMechanisms
+	}
+	return c.SASLMechanisms
+}
+
+// directTLSPort and clientPort are the well-known ports for, respectively, a
+// server that expects TLS immediately on connect and one that expects the
+// plain XML stream with StartTLS negotiated afterwards.
+const (
+	directTLSPort = "5223"
+	clientPort    = "5222"
+)
+
+// dial connects to serverName and returns the raw (or, under DirectTLS,
+// already TLS-wrapped) connection dial() should hand to xmpp.NewSession.
+//
+// dial.Dialer.DialServer is only used for the plain case: it holds its own
+// SRV/fallback candidate list, and with no SRV records that list tries
+// clientPort before directTLSPort, so letting it run under DirectTLS could
+// silently settle for a plaintext candidate instead of ever reaching the TLS
+// port. A proxy.Dialer is likewise not something dial.Dialer can wrap - its
+// Dialer field is a concrete net.Dialer, not an interface, so there is
+// nowhere to plug a proxy.Dialer in. Both cases are therefore dialed here
+// directly: straight to directTLSPort with a manual TLS handshake for
+// DirectTLS, or through the configured proxy otherwise, bypassing
+// dial.Dialer.DialServer's candidate race entirely.
+func (c *TransportConfig) dial(ctx context.Context, j jid.JID, serverName string) (net.Conn, error) {
+	if c == nil || (!c.DirectTLS && c.Proxy == "") {
+		d := dial.Dialer{}
+		if c != nil {
+			d.NoTLS = c.NoTLS
+			d.TLSConfig = c.tlsConfig(serverName)
+		}
+		return d.DialServer(ctx, "tcp", j, serverName)
+	}
+
+	port := clientPort
+	if c.DirectTLS {
+		port = directTLSPort
+	}
+	addr := net.JoinHostPort(serverName, port)
+
+	conn, err := c.rawDial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.DirectTLS {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, c.tlsConfig(serverName))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("direct TLS handshake to %s failed: %w", addr, err)
+	}
+	return tlsConn, nil
+}
+
+// rawDial opens a TCP connection to addr, through TransportConfig.Proxy when
+// set, or directly otherwise.
+func (c *TransportConfig) rawDial(ctx context.Context, addr string) (net.Conn, error) {
+	if c.Proxy == "" {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+
+	proxyURL, err := url.Parse(c.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proxy URL: %w", err)
+	}
+
+	proxyDialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build proxy dialer: %w", err)
+	}
+
+	if ctxDialer, ok := proxyDialer.(proxy.ContextDialer); ok {
+		return ctxDialer.DialContext(ctx, "tcp", addr)
+	}
+	return proxyDialer.Dial("tcp", addr)
+}
@@ -6,18 +6,53 @@ package oasis_sdk
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/sha3"
+	"hash"
 	"io"
 	"mellium.im/xmpp/stanza"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 )
 
+// sniffLen mirrors net/http.DetectContentType's documented read size.
+const sniffLen = 512
+
+// detectContentType determines the MIME type to send as Content-Type for an
+// upload. It prefers an explicit override, then sniffs the first sniffLen
+// bytes of head with net/http.DetectContentType, and falls back to
+// mime.TypeByExtension for formats the sniffer can't identify or only
+// reports generically (SVG as plain text or XML, Opus as Ogg, and similar).
+// If the extension is unknown too, it returns the sniffer's result, at
+// worst "application/octet-stream", rather than leaving it empty.
+func detectContentType(override string, filename string, head []byte) string {
+	if override != "" {
+		return override
+	}
+
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	detected := http.DetectContentType(head)
+
+	if detected == "application/octet-stream" || detected == "text/plain; charset=utf-8" ||
+		detected == "text/xml; charset=utf-8" || detected == "application/ogg" {
+		if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+			return byExt
+		}
+	}
+
+	return detected
+}
+
 // UploadRequestDetails represents the XML structure for requesting an upload slot
 // from an XMPP server. It follows the XEP-0363 specification format.
 type UploadRequestDetails struct {
@@ -95,13 +130,25 @@ func (client *XmppClient) getUploadSlot(request UploadRequestDetails) (*PutURL,
 	return &response.Slot.Put, response.Slot.Get.URL, nil
 }
 
+// UploadResult carries everything learned about a completed upload: where it
+// landed, how big it was, what content type was sent, and the digests
+// computed in-flight while the bytes were streamed to the server. Hashes is
+// keyed by the XEP-0300 hash-name ("sha-256", "sha3-256") with a
+// base64-encoded digest, ready to drop into a XEP-0447 SendFileShare call.
+type UploadResult struct {
+	GetURL      string
+	Size        int64
+	ContentType string
+	Hashes      map[string]string
+}
+
 // UploadProgress represents the current status of an upload operation
 type UploadProgress struct {
 	BytesSent  int64
 	TotalBytes int64
 	Percentage float64
-	GetURL     string // Only set when upload is complete
-	Error      error  // Set if an error occurs
+	Result     *UploadResult // Only set on the final event once the upload completes
+	Error      error         // Set if an error occurs
 }
 
 // progressReader wraps an io.Reader to track upload progress
@@ -122,11 +169,41 @@ func (pr *progressReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
-// sendProgress sends the current upload progress, including bytes sent, total bytes, percentage, any error, and getURL.
+// hashingUploadReader wraps r for progress tracking and tees every byte read
+// through SHA-256 and SHA3-256 so both digests are ready the moment the PUT
+// finishes, without a second read pass over the file or buffer. It returns
+// the progressReader (so callers can still read bytesRead for error
+// reporting), the reader the HTTP request body should use, and the hashers
+// keyed by XEP-0300 hash name for sumHashes once the body has been read.
+func hashingUploadReader(r io.Reader, totalSize int64, progressFunc func(int64)) (*progressReader, io.Reader, map[string]hash.Hash) {
+	pr := &progressReader{reader: r, totalSize: totalSize, progressFunc: progressFunc}
+	hashers := map[string]hash.Hash{
+		"sha-256":  sha256.New(),
+		"sha3-256": sha3.New256(),
+	}
+	writers := make([]io.Writer, 0, len(hashers))
+	for _, h := range hashers {
+		writers = append(writers, h)
+	}
+	return pr, io.TeeReader(pr, io.MultiWriter(writers...)), hashers
+}
+
+// sumHashes renders each hasher's digest as the base64 string XEP-0300 and
+// XEP-0447 expect inside a <hash> element.
+func sumHashes(hashers map[string]hash.Hash) map[string]string {
+	sums := make(map[string]string, len(hashers))
+	for name, h := range hashers {
+		sums[name] = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	return sums
+}
+
+// sendProgress sends the current upload progress, including bytes sent, total bytes, percentage, any error, and result.
 // It writes the update to progressChan without blocking if the channel is not ready.
 // Parameters: bytesSent is the number of bytes uploaded, totalBytes is the total size of the upload, err is any error
-// encountered, getURL is the download URL if upload completes successfully, and progressChan is the channel for progress.
-func sendProgress(bytesSent int64, totalBytes int64, err error, getURL string, progressChan chan<- UploadProgress) {
+// encountered, result is the completed upload's metadata and hashes if the upload succeeded, and progressChan is the
+// channel for progress.
+func sendProgress(bytesSent int64, totalBytes int64, err error, result *UploadResult, progressChan chan<- UploadProgress) {
 	if progressChan == nil {
 		return
 	}
@@ -135,7 +212,7 @@ func sendProgress(bytesSent int64, totalBytes int64, err error, getURL string, p
 		TotalBytes: totalBytes,
 		Percentage: float64(bytesSent) / float64(totalBytes) * 100,
 		Error:      err,
-		GetURL:     getURL,
+		Result:     result,
 	}
 	select {
 	case progressChan <- progress:
@@ -153,6 +230,7 @@ func (client *XmppClient) UploadFileFromBytes(
 	ctx context.Context,
 	filename string,
 	content []byte,
+	contentType string,
 	progressChan chan<- UploadProgress,
 ) {
 	if progressChan != nil {
@@ -160,42 +238,43 @@ func (client *XmppClient) UploadFileFromBytes(
 	}
 
 	if filename == "" || len(content) == 0 {
-		sendProgress(0, 0, errors.New("filename and content cannot be empty"), "", progressChan)
+		sendProgress(0, 0, errors.New("filename and content cannot be empty"), nil, progressChan)
 		return
 	}
 
+	detectedType := detectContentType(contentType, filename, content)
+
 	// put together data
 	request := UploadRequestDetails{
-		Filename: filepath.Base(filename),
-		Size:     int64(len(content)),
+		Filename:    filepath.Base(filename),
+		Size:        int64(len(content)),
+		ContentType: &detectedType,
 	}
 
 	// request upload slot
 	putData, getURL, err := client.getUploadSlot(request)
 	if err != nil {
-		sendProgress(0, request.Size, fmt.Errorf("failed to get upload slot: %w", err), "", progressChan)
+		sendProgress(0, request.Size, fmt.Errorf("failed to get upload slot: %w", err), nil, progressChan)
 		return
 	}
 
 	//sanity check
 	if putData == nil || getURL == "" {
-		sendProgress(0, request.Size, errors.New("upload slot is malformed"), "", progressChan)
+		sendProgress(0, request.Size, errors.New("upload slot is malformed"), nil, progressChan)
 		return
 	}
 
-	// Create a custom reader that reports progress
-	reader := &progressReader{
-		reader:       bytes.NewReader(content),
-		totalSize:    request.Size,
-		progressFunc: func(n int64) { sendProgress(n, request.Size, nil, "", progressChan) },
-	}
+	// Create a progress-tracking reader teed into SHA-256/SHA3-256 hashers
+	reader, hashed, hashers := hashingUploadReader(bytes.NewReader(content), request.Size,
+		func(n int64) { sendProgress(n, request.Size, nil, nil, progressChan) })
 
 	//create new request object
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, putData.URL, reader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, putData.URL, hashed)
 	if err != nil {
-		sendProgress(0, request.Size, fmt.Errorf("failed to create upload request: %w", err), "", progressChan)
+		sendProgress(0, request.Size, fmt.Errorf("failed to create upload request: %w", err), nil, progressChan)
 		return
 	}
+	req.Header.Set("Content-Type", detectedType)
 
 	//add auth headers
 	for _, header := range putData.Headers {
@@ -205,7 +284,7 @@ func (client *XmppClient) UploadFileFromBytes(
 	//make request
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		sendProgress(reader.bytesRead, request.Size, fmt.Errorf("failed to upload file: %w", err), "", progressChan)
+		sendProgress(reader.bytesRead, request.Size, fmt.Errorf("failed to upload file: %w", err), nil, progressChan)
 		return
 	}
 	defer resp.Body.Close()
@@ -213,12 +292,17 @@ func (client *XmppClient) UploadFileFromBytes(
 	//check if request succeeded
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		sendProgress(reader.bytesRead, request.Size,
-			fmt.Errorf("upload failed with status code: %d", resp.StatusCode), "", progressChan)
+			fmt.Errorf("upload failed with status code: %d", resp.StatusCode), nil, progressChan)
 		return
 	}
 
-	// Send final progress with GetURL
-	sendProgress(request.Size, request.Size, nil, getURL, progressChan)
+	// Send final progress with the completed upload's result
+	sendProgress(request.Size, request.Size, nil, &UploadResult{
+		GetURL:      getURL,
+		Size:        request.Size,
+		ContentType: detectedType,
+		Hashes:      sumHashes(hashers),
+	}, progressChan)
 }
 
 // UploadFile handles the complete process of uploading a file to the XMPP server.
@@ -229,6 +313,7 @@ func (client *XmppClient) UploadFileFromBytes(
 func (client *XmppClient) UploadFile(
 	ctx context.Context,
 	path string,
+	contentType string,
 	progressChan chan<- UploadProgress,
 ) {
 	if progressChan != nil {
@@ -236,14 +321,14 @@ func (client *XmppClient) UploadFile(
 	}
 
 	if path == "" {
-		sendProgress(0, 0, errors.New("path cannot be empty"), "", progressChan)
+		sendProgress(0, 0, errors.New("path cannot be empty"), nil, progressChan)
 		return
 	}
 
 	//open file
 	file, err := os.Open(path)
 	if err != nil {
-		sendProgress(0, 0, fmt.Errorf("failed to open file: %w", err), "", progressChan)
+		sendProgress(0, 0, fmt.Errorf("failed to open file: %w", err), nil, progressChan)
 		return
 	}
 	defer file.Close()
@@ -251,45 +336,57 @@ func (client *XmppClient) UploadFile(
 	//get metadata
 	fileInfo, err := file.Stat()
 	if err != nil {
-		sendProgress(0, 0, fmt.Errorf("failed to get file info: %w", err), "", progressChan)
+		sendProgress(0, 0, fmt.Errorf("failed to get file info: %w", err), nil, progressChan)
 		return
 	}
 
+	// sniff the head of the file for MIME detection, then rewind
+	head := make([]byte, sniffLen)
+	n, err := file.Read(head)
+	if err != nil && err != io.EOF {
+		sendProgress(0, 0, fmt.Errorf("failed to read file for content-type detection: %w", err), nil, progressChan)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		sendProgress(0, 0, fmt.Errorf("failed to seek file after content-type detection: %w", err), nil, progressChan)
+		return
+	}
+	detectedType := detectContentType(contentType, path, head[:n])
+
 	// put together data
 	request := UploadRequestDetails{
-		Filename: filepath.Base(path),
-		Size:     fileInfo.Size(),
+		Filename:    filepath.Base(path),
+		Size:        fileInfo.Size(),
+		ContentType: &detectedType,
 	}
 
 	// request upload slot
 	putData, getURL, err := client.getUploadSlot(request)
 	if err != nil {
-		sendProgress(0, request.Size, fmt.Errorf("failed to get upload slot: %w", err), "", progressChan)
+		sendProgress(0, request.Size, fmt.Errorf("failed to get upload slot: %w", err), nil, progressChan)
 		return
 	}
 
 	//sanity check
 	if putData == nil || getURL == "" {
-		sendProgress(0, request.Size, errors.New("upload slot is malformed"), "", progressChan)
+		sendProgress(0, request.Size, errors.New("upload slot is malformed"), nil, progressChan)
 		return
 	}
 
-	// Create a progress tracking reader
-	reader := &progressReader{
-		reader:       file,
-		totalSize:    fileInfo.Size(),
-		progressFunc: func(n int64) { sendProgress(n, fileInfo.Size(), nil, "", progressChan) },
-	}
+	// Create a progress-tracking reader teed into SHA-256/SHA3-256 hashers
+	reader, hashed, hashers := hashingUploadReader(file, fileInfo.Size(),
+		func(n int64) { sendProgress(n, fileInfo.Size(), nil, nil, progressChan) })
 
 	//create new request object with context for cancellation
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, putData.URL, reader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, putData.URL, hashed)
 	if err != nil {
-		sendProgress(0, request.Size, fmt.Errorf("failed to create upload request: %w", err), "", progressChan)
+		sendProgress(0, request.Size, fmt.Errorf("failed to create upload request: %w", err), nil, progressChan)
 		return
 	}
 
 	// explicitly set the Content-Length header
 	req.ContentLength = fileInfo.Size()
+	req.Header.Set("Content-Type", detectedType)
 
 	//add auth headers
 	for _, header := range putData.Headers {
@@ -299,7 +396,7 @@ func (client *XmppClient) UploadFile(
 	//make request
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		sendProgress(reader.bytesRead, request.Size, fmt.Errorf("failed to upload file: %w", err), "", progressChan)
+		sendProgress(reader.bytesRead, request.Size, fmt.Errorf("failed to upload file: %w", err), nil, progressChan)
 		return
 	}
 	defer resp.Body.Close()
@@ -307,10 +404,15 @@ func (client *XmppClient) UploadFile(
 	//check if request succeeded
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		sendProgress(reader.bytesRead, request.Size,
-			fmt.Errorf("upload failed with status code: %d", resp.StatusCode), "", progressChan)
+			fmt.Errorf("upload failed with status code: %d", resp.StatusCode), nil, progressChan)
 		return
 	}
 
-	// Send final progress with GetURL
-	sendProgress(request.Size, request.Size, nil, getURL, progressChan)
+	// Send final progress with the completed upload's result
+	sendProgress(request.Size, request.Size, nil, &UploadResult{
+		GetURL:      getURL,
+		Size:        request.Size,
+		ContentType: detectedType,
+		Hashes:      sumHashes(hashers),
+	}, progressChan)
 }